@@ -12,7 +12,6 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/security"
@@ -65,6 +64,11 @@ func bindRealtimeBridge(app core.App) {
 		return e.Next()
 	})
 
+	// The bridge tables hold only the state of the currently running instances
+	// so they must not be overwritten by a backup restore.
+	app.OnBackupCreate().BindFunc(excludeRealtimeBridgeTables)
+	app.OnBackupRestore().BindFunc(excludeRealtimeBridgeTables)
+
 	// Special fixes for horizontally deployed pocketbase instances.
 	app.OnCollectionCreateRequest().BindFunc(bridge.broadcastCollectionChanged)
 	app.OnCollectionUpdateRequest().BindFunc(bridge.broadcastCollectionChanged)
@@ -320,6 +324,12 @@ func (t *RealtimeBridge) heartbeatLoop(ctx context.Context) {
 	}
 }
 
+func excludeRealtimeBridgeTables(e *core.BackupEvent) error {
+	e.ExcludeTables = append(e.ExcludeTables, "_realtimeChannels", "_realtimeClients")
+
+	return e.Next()
+}
+
 func (t *RealtimeBridge) SelfChannelId() string {
 	return t.channelId
 }
@@ -423,25 +433,6 @@ func (t *RealtimeBridge) broadcastSettingsUpdated(e *core.SettingsUpdateRequestE
 
 // runPgxCommand aquires a connection from connection pool, runs the provided pgxFunc,
 // and put the connection back to the pool.
-// *dbx.DB -> *sql.DB -> *sql.Conn -> *pgx.Conn
 func runPgxCommand(ctx context.Context, app core.App, pgxFunc func(*pgx.Conn) error) error {
-	dbxDB, _ := app.NonconcurrentDB().(*dbx.DB)
-	if dbxDB == nil {
-		return fmt.Errorf("app.NonconcurrentDB() is not a *dbx.DB instance")
-	}
-	sqlDB := dbxDB.DB() // *sql.DB, a connection pool
-	sqlConn, err := sqlDB.Conn(ctx)
-	if err != nil {
-		return fmt.Errorf("error acquiring SQL connection: %w", err)
-	}
-	defer sqlConn.Close() // Put back to connection pool
-
-	return sqlConn.Raw(func(driverConn any) error {
-		stdlibConn, ok := driverConn.(*stdlib.Conn)
-		if !ok {
-			return fmt.Errorf("driverConn is not a *stdlib.Conn instance, are you using the pgx's database/sql driver?")
-		}
-		pgxConn := stdlibConn.Conn()
-		return pgxFunc(pgxConn)
-	})
+	return core.RunPgxCommand(ctx, app.NonconcurrentDB(), pgxFunc)
 }
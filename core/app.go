@@ -112,7 +112,8 @@ type App interface {
 	// ReloadSettings reinitializes and reloads the stored application settings.
 	ReloadSettings() error
 
-	// CreateBackup creates a new backup of the current app pb_data directory.
+	// CreateBackup creates a new backup of the current app pb_data directory
+	// and the Postgres data and auxiliary databases.
 	//
 	// Backups can be stored on S3 if it is configured in app.Settings().Backups.
 	//
@@ -467,6 +467,9 @@ func (app *BaseApp) Bootstrap() error {
 		// try to cleanup the pb_data temp directory (if any)
 		_ = os.RemoveAll(filepath.Join(app.DataDir(), LocalTempDirName))
 
+		// try to cleanup leftover db dumps from an interrupted backup (if any)
+		_ = os.RemoveAll(filepath.Join(app.DataDir(), LocalPostgresDumpDirName))
+
 		return nil
 	})
 
@@ -9,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"time"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/tools/archive"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
 	"github.com/pocketbase/pocketbase/tools/inflector"
@@ -23,16 +25,19 @@ const (
 	StoreKeyActiveBackup = "@activeBackup"
 )
 
-// CreateBackup creates a new backup of the current app pb_data directory.
+// CreateBackup creates a new backup of the current app pb_data directory
+// and the Postgres data and auxiliary databases.
 //
 // If name is empty, it will be autogenerated.
 // If backup with the same name exists, the new backup file will replace it.
 //
-// The backup is executed within a transaction, meaning that new writes
-// will be temporary "blocked" until the backup file is generated.
+// The databases are exported as logical dumps (the schema DDL and a COPY file
+// per table) and are stored in the [LocalPostgresDumpDirName] directory of the
+// generated archive. Each database is dumped from a single snapshot, meaning
+// that the new writes are not blocked during the backup generation.
 //
 // To safely perform the backup, it is recommended to have free disk space
-// for at least 2x the size of the pb_data directory.
+// for at least 2x the size of the pb_data directory and the databases.
 //
 // By default backups are stored in pb_data/backups
 // (the backups directory itself is excluded from the generated backup).
@@ -69,23 +74,30 @@ func (app *BaseApp) CreateBackup(ctx context.Context, name string) error {
 			return fmt.Errorf("failed to create a temp dir: %w", err)
 		}
 
+		// export the databases in a special pb_data subdirectory so that
+		// they can be archived together with the rest of the pb_data content
+		// ---
+		dumpDir := filepath.Join(e.App.DataDir(), LocalPostgresDumpDirName)
+		if err := os.RemoveAll(dumpDir); err != nil {
+			return fmt.Errorf("failed to cleanup the previous db dump dir: %w", err)
+		}
+		defer os.RemoveAll(dumpDir)
+
+		err := dumpPostgresDB(e.Context, e.App.NonconcurrentDB(), filepath.Join(dumpDir, postgresDumpDataDirName), e.ExcludeTables)
+		if err != nil {
+			return fmt.Errorf("failed to dump the data db: %w", err)
+		}
+
+		err = dumpPostgresDB(e.Context, e.App.AuxNonconcurrentDB(), filepath.Join(dumpDir, postgresDumpAuxDirName), e.ExcludeTables)
+		if err != nil {
+			return fmt.Errorf("failed to dump the auxiliary db: %w", err)
+		}
+
 		// archive pb_data in a temp directory, exluding the "backups" and the temp dirs
-		//
-		// run in transaction to temporary block other writes (transactions uses the NonconcurrentDB connection)
 		// ---
 		tempPath := filepath.Join(localTempDir, "pb_backup_"+security.PseudorandomString(6))
-		createErr := e.App.RunInTransaction(func(txApp App) error {
-			return txApp.AuxRunInTransaction(func(txApp App) error {
-				// run manual checkpoint and truncate the WAL files
-				// (errors are ignored because it is not that important and the PRAGMA may not be supported by the used driver)
-				txApp.DB().NewQuery("PRAGMA wal_checkpoint(TRUNCATE)").Execute()
-				txApp.AuxDB().NewQuery("PRAGMA wal_checkpoint(TRUNCATE)").Execute()
-
-				return archive.Create(txApp.DataDir(), tempPath, e.Exclude...)
-			})
-		})
-		if createErr != nil {
-			return createErr
+		if err := archive.Create(e.App.DataDir(), tempPath, e.Exclude...); err != nil {
+			return err
 		}
 		defer os.Remove(tempPath)
 
@@ -120,7 +132,7 @@ func (app *BaseApp) CreateBackup(ctx context.Context, name string) error {
 // NB! This feature is experimental and currently is expected to work only on UNIX based systems.
 //
 // To safely perform the restore it is recommended to have free disk space
-// for at least 2x the size of the restored pb_data backup.
+// for at least 2x the size of the restored pb_data backup and databases.
 //
 // The performed steps are:
 //
@@ -138,7 +150,11 @@ func (app *BaseApp) CreateBackup(ctx context.Context, name string) error {
 //
 //  4. Move the extracted dir content to the app "pb_data".
 //
-//  5. Restart the app (on successful app bootstap it will also remove the old pb_data).
+//  5. Replace the tables and views of the configured data and auxiliary
+//     databases with the ones from the extracted Postgres dumps
+//     (each database is restored in a single transaction).
+//
+//  6. Restart the app (on successful app bootstap it will also remove the old pb_data).
 //
 // If a failure occure during the restore process the dir changes are reverted.
 // If for whatever reason the revert is not possible, it panics.
@@ -236,12 +252,18 @@ func (app *BaseApp) RestoreBackup(ctx context.Context, name string) error {
 			}
 		}
 
-		// ensure that at least a database file exists
-		extractedDB := filepath.Join(extractedDataDir, "data.db")
-		if _, err := os.Stat(extractedDB); err != nil {
-			return fmt.Errorf("data.db file is missing or invalid: %w", err)
+		// ensure that the database dumps exist
+		extractedDumpDir := filepath.Join(extractedDataDir, LocalPostgresDumpDirName)
+		for _, dumpName := range []string{postgresDumpDataDirName, postgresDumpAuxDirName} {
+			manifest := filepath.Join(extractedDumpDir, dumpName, postgresDumpManifestName)
+			if _, err := os.Stat(manifest); err != nil {
+				return fmt.Errorf("%s db dump is missing or invalid: %w", dumpName, err)
+			}
 		}
 
+		// the dumps are restored directly from the extracted dir
+		moveExclude := append(slices.Clone(e.Exclude), LocalPostgresDumpDirName)
+
 		oldTempDataDir := filepath.Join(localTempDir, "old_pb_data_"+security.PseudorandomString(8))
 
 		replaceErr := e.App.RunInTransaction(func(txApp App) error {
@@ -249,12 +271,12 @@ func (app *BaseApp) RestoreBackup(ctx context.Context, name string) error {
 				// move the current pb_data content to a special temp location
 				// that will hold the old data between dirs replace
 				// (the temp dir will be automatically removed on the next app start)
-				if err := osutils.MoveDirContent(txApp.DataDir(), oldTempDataDir, e.Exclude...); err != nil {
+				if err := osutils.MoveDirContent(txApp.DataDir(), oldTempDataDir, moveExclude...); err != nil {
 					return fmt.Errorf("failed to move the current pb_data content to a temp location: %w", err)
 				}
 
 				// move the extracted archive content to the app's pb_data
-				if err := osutils.MoveDirContent(extractedDataDir, txApp.DataDir(), e.Exclude...); err != nil {
+				if err := osutils.MoveDirContent(extractedDataDir, txApp.DataDir(), moveExclude...); err != nil {
 					return fmt.Errorf("failed to move the extracted archive content to pb_data: %w", err)
 				}
 
@@ -268,11 +290,11 @@ func (app *BaseApp) RestoreBackup(ctx context.Context, name string) error {
 		revertDataDirChanges := func() error {
 			return e.App.RunInTransaction(func(txApp App) error {
 				return txApp.AuxRunInTransaction(func(txApp App) error {
-					if err := osutils.MoveDirContent(txApp.DataDir(), extractedDataDir, e.Exclude...); err != nil {
+					if err := osutils.MoveDirContent(txApp.DataDir(), extractedDataDir, moveExclude...); err != nil {
 						return fmt.Errorf("failed to revert the extracted dir change: %w", err)
 					}
 
-					if err := osutils.MoveDirContent(oldTempDataDir, txApp.DataDir(), e.Exclude...); err != nil {
+					if err := osutils.MoveDirContent(oldTempDataDir, txApp.DataDir(), moveExclude...); err != nil {
 						return fmt.Errorf("failed to revert old pb_data dir change: %w", err)
 					}
 
@@ -281,6 +303,25 @@ func (app *BaseApp) RestoreBackup(ctx context.Context, name string) error {
 			})
 		}
 
+		// replace the databases content
+		dumps := []struct {
+			name string
+			db   func() dbx.Builder
+		}{
+			{postgresDumpDataDirName, e.App.NonconcurrentDB},
+			{postgresDumpAuxDirName, e.App.AuxNonconcurrentDB},
+		}
+		for _, dump := range dumps {
+			restoreErr := restorePostgresDB(e.Context, dump.db(), filepath.Join(extractedDumpDir, dump.name), e.ExcludeTables)
+			if restoreErr != nil {
+				if revertErr := revertDataDirChanges(); revertErr != nil {
+					panic(revertErr)
+				}
+
+				return fmt.Errorf("failed to restore the %s db: %w", dump.name, restoreErr)
+			}
+		}
+
 		// restart the app
 		if err := e.App.Restart(); err != nil {
 			if revertErr := revertDataDirChanges(); revertErr != nil {
@@ -0,0 +1,458 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	// LocalPostgresDumpDirName is the pb_data sub directory where the
+	// Postgres logical dumps are temporary stored during backup create/restore.
+	LocalPostgresDumpDirName string = ".pb_postgres_dump"
+
+	postgresDumpDataDirName     = "data"
+	postgresDumpAuxDirName      = "auxiliary"
+	postgresDumpManifestName    = "manifest.json"
+	postgresDumpManifestVersion = 1
+)
+
+// postgresDump describes a single database logical dump.
+//
+// The dump directory contains the manifest file and one COPY (text format)
+// file per table. The schema DDL is stored in the manifest and is split into
+// statements that must be executed before and after the tables data is loaded.
+type postgresDump struct {
+	Version int                  `json:"version"`
+	Tables  []*postgresDumpTable `json:"tables"`
+
+	// PostData contains the statements that are executed after loading
+	// the tables data (foreign keys, indexes, identity sequences and views).
+	PostData []string `json:"postData"`
+}
+
+type postgresDumpTable struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"` // the copied (aka. non-generated) columns
+	DDL     string   `json:"ddl"`
+	File    string   `json:"file"`
+}
+
+// dumpPostgresDB creates a logical dump of the current schema
+// of the provided db and writes it in the dest directory.
+//
+// All tables are dumped from the same snapshot so the dump is consistent
+// without blocking the other db writes.
+func dumpPostgresDB(ctx context.Context, db dbx.Builder, dest string, excludeTables []string) error {
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+
+	return RunPgxCommand(ctx, db, func(conn *pgx.Conn) error {
+		tx, err := conn.BeginTx(ctx, pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadOnly,
+		})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		dump, err := loadPostgresDumpSchema(ctx, tx, excludeTables)
+		if err != nil {
+			return fmt.Errorf("failed to load the db schema: %w", err)
+		}
+
+		for i, table := range dump.Tables {
+			table.File = fmt.Sprintf("%04d.copy", i)
+
+			if err := copyPostgresTableTo(ctx, conn, table, filepath.Join(dest, table.File)); err != nil {
+				return fmt.Errorf("failed to dump table %q: %w", table.Name, err)
+			}
+		}
+
+		raw, err := json.Marshal(dump)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dest, postgresDumpManifestName), raw, 0644); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+}
+
+// restorePostgresDB replaces the current schema tables and views of the
+// provided db with the ones from the logical dump located in src.
+//
+// The restore is executed in a single transaction, meaning that on
+// failure the db is left unchanged.
+func restorePostgresDB(ctx context.Context, db dbx.Builder, src string, excludeTables []string) error {
+	raw, err := os.ReadFile(filepath.Join(src, postgresDumpManifestName))
+	if err != nil {
+		return err
+	}
+
+	dump := &postgresDump{}
+	if err := json.Unmarshal(raw, dump); err != nil {
+		return fmt.Errorf("invalid dump manifest: %w", err)
+	}
+
+	if dump.Version != postgresDumpManifestVersion {
+		return fmt.Errorf("unsupported dump manifest version %d", dump.Version)
+	}
+
+	return RunPgxCommand(ctx, db, func(conn *pgx.Conn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		if err := dropPostgresSchemaObjects(ctx, tx, excludeTables); err != nil {
+			return fmt.Errorf("failed to drop the existing tables: %w", err)
+		}
+
+		for _, table := range dump.Tables {
+			if slices.Contains(excludeTables, table.Name) {
+				continue
+			}
+
+			if _, err := tx.Exec(ctx, table.DDL); err != nil {
+				return fmt.Errorf("failed to create table %q: %w", table.Name, err)
+			}
+
+			if err := copyPostgresTableFrom(ctx, conn, table, filepath.Join(src, table.File)); err != nil {
+				return fmt.Errorf("failed to restore table %q data: %w", table.Name, err)
+			}
+		}
+
+		for _, stmt := range dump.PostData {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to execute %q: %w", stmt, err)
+			}
+		}
+
+		return tx.Commit(ctx)
+	})
+}
+
+func copyPostgresTableTo(ctx context.Context, conn *pgx.Conn, table *postgresDumpTable, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.PgConn().CopyTo(ctx, f, fmt.Sprintf(
+		"COPY %s (%s) TO STDOUT",
+		quotePostgresIdent(table.Name),
+		quotePostgresIdents(table.Columns),
+	))
+
+	return errors.Join(err, f.Close())
+}
+
+func copyPostgresTableFrom(ctx context.Context, conn *pgx.Conn, table *postgresDumpTable, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = conn.PgConn().CopyFrom(ctx, f, fmt.Sprintf(
+		"COPY %s (%s) FROM STDIN",
+		quotePostgresIdent(table.Name),
+		quotePostgresIdents(table.Columns),
+	))
+
+	return err
+}
+
+// dropPostgresSchemaObjects drops all views and tables from the current schema
+// (except the excluded ones) in preparation for a dump restore.
+func dropPostgresSchemaObjects(ctx context.Context, tx pgx.Tx, excludeTables []string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT c.relname, c.relkind::text
+		FROM pg_class c
+		WHERE c.relnamespace = to_regnamespace(current_schema())
+		AND c.relkind IN ('r', 'v')
+	`)
+	if err != nil {
+		return err
+	}
+
+	var views, tables []string
+
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			rows.Close()
+			return err
+		}
+
+		switch {
+		case kind == "r" && !slices.Contains(excludeTables, name):
+			tables = append(tables, name)
+		case kind == "v":
+			views = append(views, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(views) > 0 {
+		if _, err := tx.Exec(ctx, "DROP VIEW IF EXISTS "+quotePostgresIdents(views)+" CASCADE"); err != nil {
+			return err
+		}
+	}
+
+	if len(tables) > 0 {
+		if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS "+quotePostgresIdents(tables)+" CASCADE"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadPostgresDumpSchema introspects the current schema and returns
+// a dump definition with the tables DDL and the post data statements.
+func loadPostgresDumpSchema(ctx context.Context, tx pgx.Tx, excludeTables []string) (*postgresDump, error) {
+	dump := &postgresDump{Version: postgresDumpManifestVersion}
+
+	type tableInfo struct {
+		oid      uint32
+		name     string
+		unlogged bool
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.oid, c.relname, c.relpersistence = 'u'
+		FROM pg_class c
+		WHERE c.relnamespace = to_regnamespace(current_schema())
+		AND c.relkind = 'r'
+		ORDER BY c.oid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*tableInfo, error) {
+		info := &tableInfo{}
+		return info, row.Scan(&info.oid, &info.name, &info.unlogged)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var identities, foreignKeys []string
+
+	for _, info := range tables {
+		if slices.Contains(excludeTables, info.name) {
+			continue
+		}
+
+		table := &postgresDumpTable{Name: info.name}
+		quotedTable := quotePostgresIdent(info.name)
+		defs := []string{}
+
+		// columns
+		// ---
+		rows, err := tx.Query(ctx, `
+			SELECT
+				a.attname,
+				format_type(a.atttypid, a.atttypmod),
+				a.attnotnull,
+				COALESCE(pg_get_expr(d.adbin, d.adrelid), ''),
+				a.attgenerated::text,
+				a.attidentity::text,
+				CASE WHEN a.attcollation <> t.typcollation THEN COALESCE(quote_ident(co.collname), '') ELSE '' END
+			FROM pg_attribute a
+			JOIN pg_type t ON t.oid = a.atttypid
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			LEFT JOIN pg_collation co ON co.oid = a.attcollation
+			WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum
+		`, info.oid)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name, typ, defaultExpr, generated, identity, collation string
+			var notNull bool
+			if err := rows.Scan(&name, &typ, &notNull, &defaultExpr, &generated, &identity, &collation); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			def := quotePostgresIdent(name) + " " + typ
+			if collation != "" {
+				def += " COLLATE " + collation
+			}
+
+			switch {
+			case generated == "s":
+				def += " GENERATED ALWAYS AS (" + defaultExpr + ") STORED"
+			case identity == "a":
+				def += " GENERATED ALWAYS AS IDENTITY"
+			case identity == "d":
+				def += " GENERATED BY DEFAULT AS IDENTITY"
+			case defaultExpr != "":
+				def += " DEFAULT " + defaultExpr
+			}
+
+			if notNull {
+				def += " NOT NULL"
+			}
+
+			if generated != "s" {
+				table.Columns = append(table.Columns, name)
+			}
+
+			if identity != "" {
+				// continue the identity sequence from the restored max value
+				identities = append(identities, fmt.Sprintf(
+					"SELECT setval(pg_get_serial_sequence(%s, %s), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+					quotePostgresLiteral(quotedTable),
+					quotePostgresLiteral(name),
+					quotePostgresIdent(name),
+					quotedTable,
+				))
+			}
+
+			defs = append(defs, def)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// constraints
+		// (note: not-null constraints are already part of the column definitions)
+		// ---
+		rows, err = tx.Query(ctx, `
+			SELECT conname, contype::text, pg_get_constraintdef(oid)
+			FROM pg_constraint
+			WHERE conrelid = $1 AND contype IN ('p', 'u', 'c', 'x', 'f')
+			ORDER BY contype, conname
+		`, info.oid)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name, kind, constraintDef string
+			if err := rows.Scan(&name, &kind, &constraintDef); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			constraint := "CONSTRAINT " + quotePostgresIdent(name) + " " + constraintDef
+
+			// foreign keys are added after all tables data is loaded
+			if kind == "f" {
+				foreignKeys = append(foreignKeys, "ALTER TABLE "+quotedTable+" ADD "+constraint)
+			} else {
+				defs = append(defs, constraint)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		create := "CREATE TABLE "
+		if info.unlogged {
+			create = "CREATE UNLOGGED TABLE "
+		}
+		table.DDL = create + quotedTable + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)"
+
+		dump.Tables = append(dump.Tables, table)
+	}
+
+	dump.PostData = append(dump.PostData, identities...)
+	dump.PostData = append(dump.PostData, foreignKeys...)
+
+	// indexes (excluding the ones created by the pk and unique constraints)
+	// ---
+	rows, err = tx.Query(ctx, `
+		SELECT t.relname, pg_get_indexdef(i.indexrelid)
+		FROM pg_index i
+		JOIN pg_class t ON t.oid = i.indrelid
+		WHERE t.relnamespace = to_regnamespace(current_schema())
+		AND t.relkind = 'r'
+		AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = i.indexrelid)
+		ORDER BY i.indexrelid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tableName, indexDef string
+		if err := rows.Scan(&tableName, &indexDef); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if !slices.Contains(excludeTables, tableName) {
+			dump.PostData = append(dump.PostData, indexDef)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// views (in creation order to preserve the views dependencies)
+	// ---
+	rows, err = tx.Query(ctx, `
+		SELECT c.relname, pg_get_viewdef(c.oid)
+		FROM pg_class c
+		WHERE c.relnamespace = to_regnamespace(current_schema())
+		AND c.relkind = 'v'
+		ORDER BY c.oid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, viewDef string
+		if err := rows.Scan(&name, &viewDef); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		dump.PostData = append(dump.PostData, "CREATE VIEW "+quotePostgresIdent(name)+" AS "+strings.TrimSuffix(strings.TrimSpace(viewDef), ";"))
+	}
+	rows.Close()
+
+	return dump, rows.Err()
+}
+
+func quotePostgresIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func quotePostgresIdents(names []string) string {
+	quoted := make([]string, len(names))
+
+	for i, name := range names {
+		quoted[i] = quotePostgresIdent(name)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func quotePostgresLiteral(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestPostgresDumpAndRestore(t *testing.T) {
+	const testDataDir = "./pb_base_backup_postgres_test_data_dir/"
+	defer os.RemoveAll(testDataDir)
+
+	app, cleanup := NewBaseAppForTest(BaseAppConfig{DataDir: testDataDir})
+	defer cleanup()
+
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := app.DB().NewQuery(`
+		CREATE TABLE "dumpTest" (
+			"id" TEXT PRIMARY KEY DEFAULT ('r' || lower(hex(randomblob(7)))) NOT NULL,
+			"title" TEXT DEFAULT '' NOT NULL,
+			"seq" BIGINT GENERATED ALWAYS AS IDENTITY,
+			"upper" TEXT GENERATED ALWAYS AS (upper("title")) STORED
+		);
+		CREATE UNIQUE INDEX "idx_dumpTest_title" ON "dumpTest" ("title");
+		CREATE TABLE "dumpTestChild" (
+			"id" TEXT PRIMARY KEY NOT NULL,
+			"parent" TEXT REFERENCES "dumpTest" ("id") ON DELETE CASCADE
+		);
+		CREATE TABLE "dumpTestExcluded" ("id" TEXT PRIMARY KEY NOT NULL);
+		CREATE VIEW "dumpTestView" AS SELECT "id", "upper" FROM "dumpTest";
+		INSERT INTO "dumpTest" ("id", "title") VALUES ('a', 'test1'), ('b', E'test\t2');
+		INSERT INTO "dumpTestChild" ("id", "parent") VALUES ('c', 'a');
+		INSERT INTO "dumpTestExcluded" ("id") VALUES ('excluded');
+	`).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dumpDir := filepath.Join(testDataDir, LocalPostgresDumpDirName)
+	exclude := []string{"dumpTestExcluded"}
+
+	if err := dumpPostgresDB(context.Background(), app.NonconcurrentDB(), dumpDir, exclude); err != nil {
+		t.Fatalf("Failed to dump the db: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dumpDir, postgresDumpManifestName)); err != nil {
+		t.Fatalf("Expected the dump manifest to be created: %v", err)
+	}
+
+	// change the db state after the dump
+	_, err = app.DB().NewQuery(`
+		DROP VIEW "dumpTestView";
+		DELETE FROM "dumpTest" WHERE "id" = 'b';
+		INSERT INTO "dumpTest" ("id", "title") VALUES ('new', 'test3');
+		INSERT INTO "dumpTestExcluded" ("id") VALUES ('excluded2');
+		CREATE TABLE "dumpTestNew" ("id" TEXT PRIMARY KEY NOT NULL);
+	`).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restorePostgresDB(context.Background(), app.NonconcurrentDB(), dumpDir, exclude); err != nil {
+		t.Fatalf("Failed to restore the db: %v", err)
+	}
+
+	t.Run("restored table data", func(t *testing.T) {
+		rows := []dbx.NullStringMap{}
+		err := app.DB().NewQuery(`SELECT "id", "title", "upper" FROM "dumpTest" ORDER BY "id"`).All(&rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %d", len(rows))
+		}
+
+		if rows[0]["id"].String != "a" || rows[1]["id"].String != "b" {
+			t.Fatalf("Expected rows a and b, got %v and %v", rows[0]["id"].String, rows[1]["id"].String)
+		}
+
+		if rows[1]["title"].String != "test\t2" || rows[1]["upper"].String != "TEST\t2" {
+			t.Fatalf("Unexpected restored row b values: %v", rows[1])
+		}
+	})
+
+	t.Run("restored view", func(t *testing.T) {
+		var total int
+		if err := app.DB().NewQuery(`SELECT count(*) FROM "dumpTestView"`).Row(&total); err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 2 {
+			t.Fatalf("Expected 2 view rows, got %d", total)
+		}
+	})
+
+	t.Run("restored identity sequence", func(t *testing.T) {
+		_, err := app.DB().NewQuery(`INSERT INTO "dumpTest" ("id", "title") VALUES ('d', 'test4')`).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var seq int
+		if err := app.DB().NewQuery(`SELECT "seq" FROM "dumpTest" WHERE "id" = 'd'`).Row(&seq); err != nil {
+			t.Fatal(err)
+		}
+
+		if seq != 3 {
+			t.Fatalf("Expected the next identity value to be 3, got %d", seq)
+		}
+	})
+
+	t.Run("restored constraints and indexes", func(t *testing.T) {
+		_, err := app.DB().NewQuery(`INSERT INTO "dumpTest" ("id", "title") VALUES ('e', 'test1')`).Execute()
+		if err == nil {
+			t.Fatal("Expected unique index error, got nil")
+		}
+
+		if _, err := app.DB().NewQuery(`DELETE FROM "dumpTest" WHERE "id" = 'a'`).Execute(); err != nil {
+			t.Fatal(err)
+		}
+
+		var total int
+		if err := app.DB().NewQuery(`SELECT count(*) FROM "dumpTestChild"`).Row(&total); err != nil {
+			t.Fatal(err)
+		}
+		if total != 0 {
+			t.Fatalf("Expected the child row to be cascade deleted, got %d rows", total)
+		}
+	})
+
+	t.Run("excluded and new tables", func(t *testing.T) {
+		if !app.HasTable("dumpTestExcluded") {
+			t.Fatal("Expected the excluded table to be preserved")
+		}
+
+		var total int
+		if err := app.DB().NewQuery(`SELECT count(*) FROM "dumpTestExcluded"`).Row(&total); err != nil {
+			t.Fatal(err)
+		}
+		if total != 2 {
+			t.Fatalf("Expected the excluded table data to be untouched, got %d rows", total)
+		}
+
+		if app.HasTable("dumpTestNew") {
+			t.Fatal("Expected the table created after the dump to be removed")
+		}
+	})
+}
+
+func TestRestorePostgresDBInvalidDump(t *testing.T) {
+	const testDataDir = "./pb_base_backup_postgres_invalid_test_data_dir/"
+	defer os.RemoveAll(testDataDir)
+
+	app, cleanup := NewBaseAppForTest(BaseAppConfig{DataDir: testDataDir})
+	defer cleanup()
+
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+
+	dumpDir := filepath.Join(testDataDir, "invalid_dump")
+	if err := os.MkdirAll(dumpDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// missing manifest
+	if err := restorePostgresDB(context.Background(), app.NonconcurrentDB(), dumpDir, nil); err == nil {
+		t.Fatal("Expected missing manifest error, got nil")
+	}
+
+	// unsupported version
+	err := os.WriteFile(filepath.Join(dumpDir, postgresDumpManifestName), []byte(`{"version":999}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restorePostgresDB(context.Background(), app.NonconcurrentDB(), dumpDir, nil); err == nil {
+		t.Fatal("Expected unsupported version error, got nil")
+	}
+
+	// the existing tables must be untouched on error
+	if !app.HasTable("_collections") {
+		t.Fatal("Expected _collections table to still exist")
+	}
+}
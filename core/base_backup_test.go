@@ -15,10 +15,7 @@ import (
 	"github.com/pocketbase/pocketbase/tools/list"
 )
 
-// Backup in SQLite just copies the data.db file.
-// But for PostgreSQL, there are many good tools: postgres-backup-s3 or pgbackrest
-// So we disable it.
-func disabled_TestCreateBackup(t *testing.T) {
+func TestCreateBackup(t *testing.T) {
 	app, _ := tests.NewTestApp()
 	defer app.Cleanup()
 
@@ -85,10 +82,7 @@ func disabled_TestCreateBackup(t *testing.T) {
 	}
 }
 
-// Backup in SQLite just copies the data.db file.
-// But for PostgreSQL, there are many good tools: postgres-backup-s3 or pgbackrest
-// So we disable it.
-func disabled_TestRestoreBackup(t *testing.T) {
+func TestRestoreBackup(t *testing.T) {
 	app, _ := tests.NewTestApp()
 	defer app.Cleanup()
 
@@ -129,33 +123,36 @@ func verifyBackupContent(app core.App, path string) error {
 		return err
 	}
 
-	expectedRootEntries := []string{
+	expectedEntries := []string{
 		"storage",
-		"data.db",
-		"data.db-shm",
-		"data.db-wal",
-		"auxiliary.db",
-		"auxiliary.db-shm",
-		"auxiliary.db-wal",
 		".gitignore",
+		filepath.Join(core.LocalPostgresDumpDirName, "data", "manifest.json"),
+		filepath.Join(core.LocalPostgresDumpDirName, "auxiliary", "manifest.json"),
 	}
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return err
+	for _, entry := range expectedEntries {
+		if _, err := os.Stat(filepath.Join(dir, entry)); err != nil {
+			return fmt.Errorf("Missing expected %q entry: %w", entry, err)
+		}
 	}
 
-	if len(entries) != len(expectedRootEntries) {
-		names := getEntryNames(entries)
-		return fmt.Errorf("Expected %d backup files, got %d: \n%v", len(expectedRootEntries), len(entries), names)
+	unexpectedEntries := []string{
+		core.LocalBackupsDirName,
+		core.LocalTempDirName,
+		"lost+found",
 	}
 
-	for _, entry := range entries {
-		if !list.ExistInSliceWithRegex(entry.Name(), expectedRootEntries) {
-			return fmt.Errorf("Didn't expect %q entry", entry.Name())
+	for _, entry := range unexpectedEntries {
+		if _, err := os.Stat(filepath.Join(dir, entry)); err == nil {
+			return fmt.Errorf("Didn't expect %q entry", entry)
 		}
 	}
 
+	// the dump dir should be removed from the pb_data after the backup generation
+	if _, err := os.Stat(filepath.Join(app.DataDir(), core.LocalPostgresDumpDirName)); err == nil {
+		return fmt.Errorf("Expected the %q dir to be removed", core.LocalPostgresDumpDirName)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pocketbase/dbx"
+)
+
+// RunPgxCommand acquires a dedicated connection from the provided db pool,
+// passes the underlying *pgx.Conn to pgxFunc and puts the connection back
+// to the pool once pgxFunc returns.
+//
+// It is useful for Postgres specific operations that are not available
+// through database/sql (eg. COPY, LISTEN/NOTIFY, session level advisory locks).
+//
+// *dbx.DB -> *sql.DB -> *sql.Conn -> *pgx.Conn
+func RunPgxCommand(ctx context.Context, db dbx.Builder, pgxFunc func(*pgx.Conn) error) error {
+	dbxDB, _ := db.(*dbx.DB)
+	if dbxDB == nil {
+		return errors.New("the provided db builder is not a *dbx.DB instance (maybe it is a transaction?)")
+	}
+
+	sqlConn, err := dbxDB.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close() // put back to the connection pool
+
+	return sqlConn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("driverConn is not a *stdlib.Conn instance, are you using the pgx's database/sql driver?")
+		}
+
+		return pgxFunc(stdlibConn.Conn())
+	})
+}
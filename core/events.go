@@ -96,6 +96,10 @@ type BackupEvent struct {
 	Context context.Context
 	Name    string   // the name of the backup to create/restore.
 	Exclude []string // list of dir entries to exclude from the backup create/restore.
+
+	// ExcludeTables is a list of db tables to exclude from the Postgres dumps
+	// (the excluded tables are also left untouched on backup restore).
+	ExcludeTables []string
 }
 
 type ServeEvent struct {